@@ -13,7 +13,6 @@ import (
 	"github.com/anacrolix/torrent/mse"
 	"github.com/go-resty/resty/v2"
 	"github.com/jellydator/ttlcache/v3"
-	"github.com/rs/zerolog/log"
 	"go.uber.org/atomic"
 	"golang.org/x/exp/maps"
 	"golang.org/x/sync/semaphore"
@@ -21,11 +20,17 @@ import (
 	"tyr/internal/config"
 	"tyr/internal/meta"
 	imse "tyr/internal/mse"
+	"tyr/internal/peer"
 	"tyr/internal/pkg/global"
 	"tyr/internal/pkg/gslice"
+	"tyr/internal/pkg/log"
 )
 
 func New(cfg config.Config, sessionPath string) *Client {
+	if err := log.Configure(cfg.App.LogFormat, cfg.App.LogLevel, cfg.App.LogFilter); err != nil {
+		panic(err)
+	}
+
 	tr := &http.Transport{
 		MaxIdleConns:       cfg.App.MaxHTTPParallel,
 		IdleConnTimeout:    30 * time.Second,
@@ -50,10 +55,11 @@ func New(cfg config.Config, sessionPath string) *Client {
 		panic(fmt.Sprintf("invalid `application.crypto` config %q, only 'prefer'(default) 'prefer-not', 'disable' or 'force' are allowed", cfg.App.Crypto))
 	}
 
-	return &Client{
+	c := &Client{
 		Config: cfg,
 		ctx:    ctx,
 		cancel: cancel,
+		log:    log.For("client"),
 		ch:     ttlcache.New[netip.AddrPort, connHistory](),
 		//sem:    semaphore.NewWeighted(int64(cfg.App.PeersLimit)),
 		sem:         semaphore.NewWeighted(50),
@@ -64,18 +70,70 @@ func New(cfg config.Config, sessionPath string) *Client {
 		mseDisabled: mseDisabled,
 		mseSelector: mseSelector,
 		sessionPath: sessionPath,
+		hasher:      newPieceHasherPool(cfg.App.HashWorkers),
+	}
+
+	go c.acceptConnections()
+
+	return c
+}
+
+// acceptConnections dispatches connections queued on connChan (by whatever
+// listener accepted them) to the Download named by their handshake's info
+// hash, for the life of the Client.
+func (c *Client) acceptConnections() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case ic := <-c.connChan:
+			c.handleIncomingConn(ic)
+		}
+	}
+}
+
+// handleIncomingConn registers ic as a new Peer on the Download whose hash
+// matches ic.infoHash, the same way an outgoing dial would via
+// Download.registerPeer, or closes the connection if no such Download is
+// loaded.
+func (c *Client) handleIncomingConn(ic incomingConn) {
+	d, ok := c.findDownload(ic.infoHash)
+	if !ok {
+		_ = ic.conn.Close()
+		return
 	}
+
+	p := peer.NewIncoming(ic.conn, ic.infoHash, d.numPieces, ic.addr.String())
+	d.registerPeer(ic.addr, p)
 }
 
+// findDownload returns the loaded Download whose info hash is infoHash.
+func (c *Client) findDownload(infoHash metainfo.Hash) (*Download, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	for _, d := range c.downloads {
+		if d.hash == infoHash {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
+
+// incomingConn is a connection accepted off a listener, still unassociated
+// with any Download until its handshake's info hash is matched against one.
 type incomingConn struct {
-	conn net.Conn
-	addr netip.AddrPort
+	conn     net.Conn
+	addr     netip.AddrPort
+	infoHash metainfo.Hash
 }
 
 type Client struct {
 	ctx             context.Context
 	http            *resty.Client
 	cancel          context.CancelFunc
+	log             log.Logger
 	downloadMap     map[meta.Hash]*Download
 	infoHashes      []meta.Hash
 	mseKeys         mse.SecretKeyIter
@@ -88,13 +146,18 @@ type Client struct {
 	checkQueue      []meta.Hash
 	Config          config.Config
 	connectionCount atomic.Uint32
-	m               sync.RWMutex
-	checkQueueLock  sync.Mutex
-	mseDisabled     bool
+	// droppedConnections counts peers dropped through Client.banPeer
+	// (smart-ban drops), for AggStats; other connection teardowns are not
+	// counted. Like connectionCount it only ever goes up.
+	droppedConnections atomic.Uint64
+	hasher             *pieceHasherPool
+	m                  sync.RWMutex
+	checkQueueLock     sync.Mutex
+	mseDisabled        bool
 }
 
 func (c *Client) AddTorrent(m *metainfo.MetaInfo, info meta.Info, downloadPath string, tags []string) error {
-	log.Info().Msgf("try add torrent %s", info.Hash)
+	c.log.Info().Msgf("try add torrent %s", info.Hash)
 
 	c.m.RLock()
 	if _, ok := c.downloadMap[info.Hash]; ok {
@@ -129,4 +192,4 @@ func (c *Client) checkComplete(d *Download) {
 	defer c.m.Unlock()
 
 	c.checkQueue = gslice.Remove(c.checkQueue, d.info.Hash)
-}
\ No newline at end of file
+}
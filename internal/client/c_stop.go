@@ -5,13 +5,12 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/rs/zerolog/log"
 	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/conc/panics"
 )
 
 func (c *Client) Shutdown() {
-	log.Info().Msg("core shutting down...")
+	c.log.Info().Msg("core shutting down...")
 
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -19,6 +18,10 @@ func (c *Client) Shutdown() {
 	c.saveSession()
 
 	c.cancel()
+
+	// Stop the hasher pool only once nothing can Enqueue into it anymore,
+	// i.e. after c.cancel, so its workers exit instead of leaking.
+	c.hasher.Close()
 }
 
 func (c *Client) saveSession() *panics.Recovered {
@@ -31,13 +34,13 @@ func (c *Client) saveSession() *panics.Recovered {
 
 			b, err := d.MarshalBinary()
 			if err != nil {
-				log.Err(err).Msg("failed to save download")
+				c.log.Err(err).Msg("failed to save download")
 				return
 			}
 
 			err = os.WriteFile(filepath.Join(c.sessionPath, "torrents", fmt.Sprintf("%x.resume", d.hash)), b, os.ModePerm)
 			if err != nil {
-				log.Err(err).Msg("failed to save download")
+				c.log.Err(err).Msg("failed to save download")
 			}
 		})
 	}
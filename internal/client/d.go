@@ -15,15 +15,24 @@ import (
 	"github.com/mxk/go-flowrate/flowrate"
 	"github.com/negrel/assert"
 	"github.com/puzpuzpuz/xsync/v3"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/valyala/bytebufferpool"
 	"go.uber.org/atomic"
 
+	"tyr/internal/peer"
 	"tyr/internal/pkg/bm"
+	"tyr/internal/pkg/log"
 	"tyr/internal/req"
 )
 
+// webSeedConcurrency bounds how many ranged GETs a single webseed peer may
+// have in flight at once, the HTTP analogue of Client.sem for TCP peers.
+const webSeedConcurrency = 4
+
+// resChanBufferSize is how many completed blocks can queue up on
+// Download.ResChan before a sender (peer or webseed) blocks. It only needs
+// to absorb a short burst; the reader is expected to keep up.
+const resChanBufferSize = 64
+
 type State uint8
 
 //go:generate stringer -type=State
@@ -40,7 +49,7 @@ type Download struct {
 	info              metainfo.Info
 	meta              metainfo.MetaInfo
 	reqHistory        *xsync.MapOf[uint32, downloadReq]
-	log               zerolog.Logger
+	log               log.Logger
 	ctx               context.Context
 	err               error
 	cancel            context.CancelFunc
@@ -51,34 +60,45 @@ type Download struct {
 	ResChan           chan req.Response
 	conn              *xsync.MapOf[netip.AddrPort, *Peer]
 	connectionHistory *xsync.MapOf[netip.AddrPort, connHistory]
-	bm                *bm.Bitmap
-	PieceData         *xsync.MapOf[uint32, []byte]
-	basePath          string
-	key               string
-	downloadDir       string
-	tags              []string
-	pieceInfo         []pieceInfo
-	trackers          []TrackerTier
-	peers             []netip.AddrPort
-	totalLength       int64
-	downloaded        atomic.Int64
-	done              atomic.Bool
-	uploaded          atomic.Int64
-	completed         atomic.Int64
-	checkProgress     atomic.Int64
-	uploadAtStart     int64
-	downloadAtStart   int64
-	lazyInitialized   atomic.Bool
-	seq               atomic.Bool
-	m                 sync.RWMutex
-	peersMutex        sync.RWMutex
-	connMutex         sync.RWMutex
-	numPieces         uint32
-	announcePending   stdSync.Bool
-	hash              metainfo.Hash
-	peerID            PeerID
-	state             State
-	private           bool
+	// webseeds holds BEP-19 HTTP sources, keyed by a synthetic AddrPort
+	// (there's no real socket to key on). They are not TCP peers so they
+	// live in their own map rather than conn.
+	webseeds  *xsync.MapOf[netip.AddrPort, *peer.WebSeedPeer]
+	bm        *bm.Bitmap
+	smartBan  *smartBanCache
+	PieceData *xsync.MapOf[uint32, []byte]
+	// pieceReceived tracks bytes already assembled into PieceData for a
+	// piece still in flight, guarded by pieceMu since it's written by
+	// consumeBlocks and cleared by onPieceHashFail, which run on different
+	// goroutines.
+	pieceReceived   map[uint32]int64
+	basePath        string
+	key             string
+	downloadDir     string
+	tags            []string
+	pieceInfo       []pieceInfo
+	trackers        []TrackerTier
+	peers           []netip.AddrPort
+	totalLength     int64
+	downloaded      atomic.Int64
+	done            atomic.Bool
+	uploaded        atomic.Int64
+	completed       atomic.Int64
+	checkProgress   atomic.Int64
+	uploadAtStart   int64
+	downloadAtStart int64
+	lazyInitialized atomic.Bool
+	seq             atomic.Bool
+	m               sync.RWMutex
+	peersMutex      sync.RWMutex
+	connMutex       sync.RWMutex
+	pieceMu         sync.Mutex
+	numPieces       uint32
+	announcePending stdSync.Bool
+	hash            metainfo.Hash
+	peerID          PeerID
+	state           State
+	private         bool
 }
 
 func (c *Client) NewDownload(m *metainfo.MetaInfo, info metainfo.Info, basePath string, tags []string) *Download {
@@ -97,7 +117,7 @@ func (c *Client) NewDownload(m *metainfo.MetaInfo, info metainfo.Info, basePath
 		cancel:   cancel,
 		meta:     *m,
 		c:        c,
-		log:      log.With().Hex("info_hash", infoHash.Bytes()).Logger(),
+		log:      log.For("download").With("info_hash", infoHash.HexString()).With("name", canonicalName(info, infoHash)),
 		state:    Checking,
 		peerID:   NewPeerID(),
 		tags:     tags,
@@ -111,16 +131,20 @@ func (c *Client) NewDownload(m *metainfo.MetaInfo, info metainfo.Info, basePath
 		totalLength:       info.TotalLength(),
 		info:              info,
 		hash:              infoHash,
+		ResChan:           make(chan req.Response, resChanBufferSize),
 		conn:              xsync.NewMapOf[netip.AddrPort, *Peer](),
 		connectionHistory: xsync.NewMapOf[netip.AddrPort, connHistory](),
+		webseeds:          xsync.NewMapOf[netip.AddrPort, *peer.WebSeedPeer](),
 
-		pieceInfo: buildPieceInfos(info),
-		numPieces: uint32(n),
-		PieceData: xsync.NewMapOf[uint32, []byte](),
+		pieceInfo:     buildPieceInfos(info),
+		numPieces:     uint32(n),
+		PieceData:     xsync.NewMapOf[uint32, []byte](),
+		pieceReceived: make(map[uint32]int64),
 
 		//key:
 		// there maybe 1 uint64 extra data here.
 		bm:          bm.New(),
+		smartBan:    newSmartBanCache(),
 		private:     private,
 		downloadDir: basePath,
 	}
@@ -131,10 +155,67 @@ func (c *Client) NewDownload(m *metainfo.MetaInfo, info metainfo.Info, basePath
 	assert.Equal(uint32(len(d.pieceInfo)), d.numPieces)
 
 	d.setAnnounceList(m)
+	d.addWebSeeds(m)
+
+	go d.consumeBlocks()
 
 	return d
 }
 
+// addWebSeeds creates one WebSeedPeer per BEP-19 url-list entry and
+// registers it alongside the torrent's regular BitTorrent peers as a source
+// of blocks for the request scheduler, wiring its responses into d.ResChan
+// and the smart-ban cache exactly like a TCP peer's.
+func (d *Download) addWebSeeds(m *metainfo.MetaInfo) {
+	i := 0
+	for _, u := range m.UrlList {
+		if u == "" {
+			continue
+		}
+
+		addr := syntheticWebSeedAddr(i)
+		i++
+
+		w := peer.NewWebSeed(u, d.info, d.hash, d.c.http.GetClient(), webSeedConcurrency)
+		w.SetResChan(d.ResChan)
+		w.SetBlockReporter(func(res req.Response) {
+			d.recordBlock(addr, res.PieceIndex, res.Begin, res.Data)
+		})
+		d.webseeds.Store(addr, w)
+	}
+}
+
+// syntheticWebSeedAddr builds a stand-in AddrPort for the i-th webseed of a
+// Download, used only as a map/connHistory key since a webseed has no real
+// socket address. The unspecified address keeps it visibly distinct from
+// any real peer.
+func syntheticWebSeedAddr(i int) netip.AddrPort {
+	return netip.AddrPortFrom(netip.IPv6Unspecified(), uint16(i+1))
+}
+
+// requestFromWebSeed hands r to the first live webseed it finds, so the
+// request scheduler can use webseeds as just another block source. It
+// returns false if there are no webseeds, all of them are dead, or every
+// live one failed to serve r.
+func (d *Download) requestFromWebSeed(r req.Request) bool {
+	var ok bool
+	d.webseeds.Range(func(_ netip.AddrPort, w *peer.WebSeedPeer) bool {
+		if w.Dead() {
+			return true
+		}
+
+		if err := w.Request(r); err != nil {
+			d.log.Warn().Err(err).Msg("webseed request failed")
+			return true
+		}
+
+		ok = true
+		return false
+	})
+
+	return ok
+}
+
 func (d *Download) Move(target string) error {
 	return errors.New("not implemented")
 }
@@ -193,4 +274,7 @@ type connHistory struct {
 	err       error
 	timeout   bool
 	connected bool
+	// banned marks a peer dropped by the smart-ban subsystem rather than an
+	// ordinary failed/closed connection; it should not be retried.
+	banned bool
 }
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"runtime"
+	"time"
+
+	"github.com/mxk/go-flowrate/flowrate"
+)
+
+// pieceHashJob is one piece waiting to be verified against info.Pieces.
+type pieceHashJob struct {
+	d     *Download
+	piece uint32
+}
+
+// pieceHasherPool decouples "piece bytes complete" from "piece hashed and
+// marked done": blocks keep landing in PieceData while a bounded set of
+// workers streams finished pieces through SHA-1 in the background, so
+// hashing on a slow CPU no longer stalls the network on a fast link.
+type pieceHasherPool struct {
+	jobs chan pieceHashJob
+	// speed tracks bytes hashed per second across all workers, exposed via
+	// AggStats.HashRate so users can tell when hashing, not the network, is
+	// the bottleneck.
+	speed *flowrate.Monitor
+}
+
+// newPieceHasherPool starts workers goroutines backed by a bounded job
+// queue; jobs is sized a few deep per worker so a burst of completed pieces
+// queues up rather than blocking the receiver thread, but a queue that's
+// consistently full still applies backpressure instead of growing without
+// bound.
+func newPieceHasherPool(workers int) *pieceHasherPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	p := &pieceHasherPool{
+		jobs:  make(chan pieceHashJob, workers*4),
+		speed: flowrate.New(time.Second, time.Second),
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+func (p *pieceHasherPool) worker() {
+	for job := range p.jobs {
+		p.hash(job.d, job.piece)
+	}
+}
+
+// Close stops every worker once it's done with its current job, by closing
+// jobs. It must be called exactly once, after the last Enqueue, or workers
+// leak for the life of the process; Client.Shutdown does this.
+func (p *pieceHasherPool) Close() {
+	close(p.jobs)
+}
+
+// Enqueue schedules piece for hashing once its last block has arrived. It
+// blocks when every worker is busy and the queue is already full, which is
+// the pool's backpressure: a download can't buffer unboundedly many
+// unverified pieces in memory while waiting for a slow CPU to catch up.
+func (p *pieceHasherPool) Enqueue(d *Download, piece uint32) {
+	p.jobs <- pieceHashJob{d: d, piece: piece}
+}
+
+// HashRate returns the current hashing throughput in bytes/sec, averaged
+// over the pool's flowrate window.
+func (p *pieceHasherPool) HashRate() float64 {
+	return p.speed.Status().CurRate
+}
+
+// hash verifies a single piece's bytes against info.Pieces. On success it
+// marks the piece done and wakes anything waiting on d.cond; on failure it
+// hands the piece off to the smart-ban path so the offending peer can be
+// identified, then frees the buffer so the blocks get re-requested.
+func (p *pieceHasherPool) hash(d *Download, piece uint32) {
+	buf, ok := d.PieceData.Load(piece)
+	if !ok {
+		return
+	}
+
+	p.speed.Update(len(buf))
+
+	sum := sha1.Sum(buf)
+	want := d.info.Pieces[piece*20 : piece*20+20]
+
+	if bytes.Equal(sum[:], want) {
+		d.PieceData.Delete(piece)
+		d.bm.Set(piece)
+		d.completed.Add(int64(len(buf)))
+		d.onPieceHashOK(piece)
+
+		d.m.Lock()
+		d.cond.Broadcast()
+		d.m.Unlock()
+
+		return
+	}
+
+	d.log.Warn().Uint32("piece", piece).Msg("piece hash mismatch, re-requesting its blocks")
+
+	d.onPieceHashFail(piece, buf)
+	d.PieceData.Delete(piece)
+}
+
+// EnqueuePieceHash schedules piece for verification on the client's shared
+// hasher pool. It should be called as soon as a piece's last block is
+// written into d.PieceData, so hashing can run concurrently with whatever
+// other pieces are still receiving blocks.
+func (d *Download) EnqueuePieceHash(piece uint32) {
+	d.c.hasher.Enqueue(d, piece)
+}
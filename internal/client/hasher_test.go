@@ -0,0 +1,102 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/mxk/go-flowrate/flowrate"
+	"github.com/puzpuzpuz/xsync/v3"
+
+	"tyr/internal/pkg/log"
+)
+
+// newTestDownload returns a *Download with just enough of its fields
+// populated to exercise pieceHasherPool.hash's failure path: a single piece
+// whose bytes will never match the (zeroed) expected hash in info.Pieces.
+func newTestDownload() *Download {
+	return &Download{
+		log:           log.For("test"),
+		info:          metainfo.Info{PieceLength: 4, Pieces: make([]byte, 20)},
+		numPieces:     1,
+		totalLength:   4,
+		PieceData:     xsync.NewMapOf[uint32, []byte](),
+		pieceReceived: make(map[uint32]int64),
+		smartBan:      newSmartBanCache(),
+	}
+}
+
+func TestPieceHasherPool_HashMismatchClearsProgressAndBuffer(t *testing.T) {
+	p := &pieceHasherPool{
+		jobs:  make(chan pieceHashJob),
+		speed: flowrate.New(time.Second, time.Second),
+	}
+
+	d := newTestDownload()
+	d.PieceData.Store(0, []byte("AAAA"))
+	d.pieceReceived[0] = 4
+
+	p.hash(d, 0)
+
+	if _, ok := d.PieceData.Load(0); ok {
+		t.Fatal("expected a failed piece's buffer to be dropped from PieceData")
+	}
+
+	d.pieceMu.Lock()
+	_, stillTracked := d.pieceReceived[0]
+	d.pieceMu.Unlock()
+	if stillTracked {
+		t.Fatal("expected a failed piece's progress to be cleared so it can be re-assembled")
+	}
+}
+
+func TestPieceHasherPool_HashMissingBufferIsNoop(t *testing.T) {
+	p := &pieceHasherPool{
+		jobs:  make(chan pieceHashJob),
+		speed: flowrate.New(time.Second, time.Second),
+	}
+
+	// No buffer was ever stored for piece 0; hash must return without
+	// touching anything it would otherwise need to dereference.
+	p.hash(newTestDownload(), 0)
+}
+
+func TestPieceHasherPool_EnqueueBlocksWhenFull(t *testing.T) {
+	p := &pieceHasherPool{
+		jobs:  make(chan pieceHashJob, 1),
+		speed: flowrate.New(time.Second, time.Second),
+	}
+
+	p.Enqueue(nil, 0)
+
+	done := make(chan struct{})
+	go func() {
+		p.Enqueue(nil, 1)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected Enqueue to block once the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-p.jobs
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the blocked Enqueue to unblock once a slot freed up")
+	}
+}
+
+func TestPieceHasherPool_EnqueueAfterClosePanics(t *testing.T) {
+	p := newPieceHasherPool(2)
+	p.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Enqueue on a closed pool to panic")
+		}
+	}()
+	p.Enqueue(nil, 0)
+}
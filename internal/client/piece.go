@@ -0,0 +1,58 @@
+package client
+
+import (
+	"tyr/internal/req"
+)
+
+// consumeBlocks reads completed blocks off ResChan, the common sink every
+// registered Peer and WebSeedPeer writes accepted data to, and assembles
+// them into PieceData. It's the only reader of ResChan and runs for the
+// life of the Download.
+func (d *Download) consumeBlocks() {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case res := <-d.ResChan:
+			d.handleBlock(res)
+		}
+	}
+}
+
+// pieceLength returns how many bytes piece holds. Every piece is
+// info.PieceLength bytes except, usually, the last one, which is whatever
+// is left over.
+func (d *Download) pieceLength(piece uint32) int64 {
+	if piece == d.numPieces-1 {
+		if rem := d.totalLength % d.info.PieceLength; rem != 0 {
+			return rem
+		}
+	}
+	return d.info.PieceLength
+}
+
+// handleBlock writes a single accepted block into its piece's buffer in
+// PieceData, allocating the buffer on the block that starts it, and
+// enqueues the piece for hashing once every one of its bytes has arrived.
+func (d *Download) handleBlock(res req.Response) {
+	buf, ok := d.PieceData.Load(res.PieceIndex)
+	if !ok {
+		buf = make([]byte, d.pieceLength(res.PieceIndex))
+		d.PieceData.Store(res.PieceIndex, buf)
+	}
+
+	copy(buf[res.Begin:], res.Data)
+	d.downloaded.Add(int64(len(res.Data)))
+
+	d.pieceMu.Lock()
+	d.pieceReceived[res.PieceIndex] += int64(len(res.Data))
+	done := d.pieceReceived[res.PieceIndex] >= int64(len(buf))
+	if done {
+		delete(d.pieceReceived, res.PieceIndex)
+	}
+	d.pieceMu.Unlock()
+
+	if done {
+		d.EnqueuePieceHash(res.PieceIndex)
+	}
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"errors"
+	"hash/maphash"
+	"net/netip"
+	"sync"
+	"time"
+
+	"tyr/internal/req"
+)
+
+// ErrSmartBanned is recorded in connHistory for peers dropped by the
+// smart-ban subsystem, as opposed to an ordinary connection/protocol error.
+var ErrSmartBanned = errors.New("peer banned by smart-ban: sent data for a piece that failed hash check")
+
+// smartBanTTL is how long a smart-banned peer is kept out of Client.ch.
+// Unlike an ordinary failed-connection backoff this is deliberately long:
+// a peer that forged piece data is not worth retrying.
+const smartBanTTL = 24 * time.Hour
+
+// smartBanBlock is the provenance of a single accepted block: who sent it
+// and a cheap keyed hash of its bytes, kept instead of the block itself so
+// the memory cost is a few bytes per block rather than the whole piece.
+type smartBanBlock struct {
+	peer   netip.AddrPort
+	begin  uint32
+	length uint32
+	digest uint64
+}
+
+// smartBanCache accumulates block provenance for the pieces of a single
+// Download that are still in flight. When a piece fails its hash check, the
+// cache lets the caller replay each contributor's digest against the bytes
+// actually sitting in the piece buffer: a peer whose digest still matches
+// the (bad) buffer at its offset is the one whose data is there, so it gets
+// blamed; a peer whose digest no longer matches (its block was since
+// overwritten by someone else) is exonerated. The digests for a piece are
+// dropped once that piece verifies, win or lose the search for a culprit.
+type smartBanCache struct {
+	seed maphash.Seed
+	mu   sync.Mutex
+	// pieces maps piece index to every block recorded for it since the
+	// last time the piece was hashed successfully.
+	pieces map[uint32][]smartBanBlock
+}
+
+func newSmartBanCache() *smartBanCache {
+	return &smartBanCache{
+		seed:   maphash.MakeSeed(),
+		pieces: make(map[uint32][]smartBanBlock),
+	}
+}
+
+func (s *smartBanCache) hash(b []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
+
+// Record should be called as soon as a block is accepted from a peer and
+// written into the piece buffer, while the bytes are still available.
+func (s *smartBanCache) Record(piece uint32, peer netip.AddrPort, begin uint32, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pieces[piece] = append(s.pieces[piece], smartBanBlock{
+		peer:   peer,
+		begin:  begin,
+		length: uint32(len(data)),
+		digest: s.hash(data),
+	})
+}
+
+// Judge replays the recorded digests for piece against buf, the full piece
+// data that just failed its hash check, and returns the set of peers to
+// blame. A peer is blamed when the bytes it originally sent are still what's
+// in buf at that offset; a peer whose recorded digest no longer matches
+// (because a later, different block overwrote that span) is left alone.
+func (s *smartBanCache) Judge(piece uint32, buf []byte) []netip.AddrPort {
+	s.mu.Lock()
+	blocks := s.pieces[piece]
+	s.mu.Unlock()
+
+	var guilty []netip.AddrPort
+	for _, b := range blocks {
+		if int(b.begin)+int(b.length) > len(buf) {
+			continue
+		}
+
+		if s.hash(buf[b.begin:b.begin+b.length]) == b.digest {
+			guilty = append(guilty, b.peer)
+		}
+	}
+
+	return guilty
+}
+
+// Forget discards every recorded block for piece. It must be called once
+// the piece has hashed successfully, so the cache doesn't grow without
+// bound over the life of a download.
+func (s *smartBanCache) Forget(piece uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pieces, piece)
+}
+
+// recordBlock records the provenance of a just-accepted block, to be
+// replayed later if the piece it belongs to fails its hash check.
+func (d *Download) recordBlock(peer netip.AddrPort, piece uint32, begin uint32, data []byte) {
+	d.smartBan.Record(piece, peer, begin, data)
+}
+
+// registerPeer wires a newly connected Peer into this Download: it starts
+// delivering accepted blocks onto d.ResChan and reporting each one's
+// provenance to the smart-ban cache, then makes the peer visible in d.conn.
+// Whatever dials out to or accepts connections for this Download's peers
+// must call this before the connection can contribute any data; it's the
+// one chokepoint both paths share, so it's also where connectionCount is
+// incremented.
+func (d *Download) registerPeer(addr netip.AddrPort, p *Peer) {
+	p.SetResChan(d.ResChan)
+	p.SetBlockReporter(func(res req.Response) {
+		d.recordBlock(addr, res.PieceIndex, res.Begin, res.Data)
+	})
+
+	d.conn.Store(addr, p)
+	d.c.connectionCount.Add(1)
+}
+
+// onPieceHashFail is called by the piece hasher when a piece's bytes don't
+// match info.Pieces. It blames and bans whichever peers' recorded blocks are
+// still sitting in buf, leaving everyone else connected, and clears any
+// assembly progress recorded for piece so the next block that arrives for
+// it starts a fresh buffer rather than appending to the bad one. d.bm never
+// had piece's bit set for it to begin with, so it stays eligible to be
+// re-requested like any other missing piece.
+func (d *Download) onPieceHashFail(piece uint32, buf []byte) {
+	for _, addr := range d.smartBan.Judge(piece, buf) {
+		d.banPeer(addr)
+	}
+
+	d.pieceMu.Lock()
+	delete(d.pieceReceived, piece)
+	d.pieceMu.Unlock()
+}
+
+// onPieceHashOK is called once piece has verified, dropping its smart-ban
+// history since there's no longer a failure to explain.
+func (d *Download) onPieceHashOK(piece uint32) {
+	d.smartBan.Forget(piece)
+}
+
+// banPeer drops addr from this download and blacklists it for smartBanTTL so
+// it won't be reconnected to any download sharing this Client.
+func (d *Download) banPeer(addr netip.AddrPort) {
+	d.connectionHistory.Store(addr, connHistory{
+		lastTry: time.Now(),
+		err:     ErrSmartBanned,
+		banned:  true,
+	})
+
+	if p, ok := d.conn.LoadAndDelete(addr); ok {
+		_ = p.Conn.Close()
+	}
+
+	d.log.Warn().Stringer("addr", addr).Msg("smart-ban: dropped peer that sent bad piece data")
+
+	d.c.banPeer(addr, ErrSmartBanned)
+}
+
+// banPeer records addr in the client-wide ttlcache so it is kept out of
+// every download's peer list for smartBanTTL, not just the one it was
+// caught poisoning.
+func (c *Client) banPeer(addr netip.AddrPort, reason error) {
+	c.ch.Set(addr, connHistory{
+		lastTry: time.Now(),
+		err:     reason,
+		banned:  true,
+	}, smartBanTTL)
+
+	c.droppedConnections.Add(1)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"net/netip"
+	"testing"
+)
+
+var (
+	peerA = netip.MustParseAddrPort("10.0.0.1:6881")
+	peerB = netip.MustParseAddrPort("10.0.0.2:6881")
+)
+
+func TestSmartBanCache_JudgeBlamesWhoeverDataIsStillThere(t *testing.T) {
+	s := newSmartBanCache()
+
+	buf := make([]byte, 32)
+	s.Record(0, peerA, 0, []byte("aaaaaaaaaaaaaaaa"))
+	s.Record(0, peerB, 16, []byte("bbbbbbbbbbbbbbbb"))
+
+	copy(buf[0:16], "aaaaaaaaaaaaaaaa")
+	copy(buf[16:32], "bbbbbbbbbbbbbbbb")
+
+	guilty := s.Judge(0, buf)
+	if len(guilty) != 2 {
+		t.Fatalf("expected both contributors blamed, got %v", guilty)
+	}
+}
+
+func TestSmartBanCache_JudgeExoneratesOverwrittenBlock(t *testing.T) {
+	s := newSmartBanCache()
+
+	buf := make([]byte, 32)
+	s.Record(0, peerA, 0, []byte("aaaaaaaaaaaaaaaa"))
+	s.Record(0, peerB, 16, []byte("bbbbbbbbbbbbbbbb"))
+
+	// peerA's span was since overwritten by a third, unrecorded source;
+	// only peerB's bytes are still the ones it sent.
+	copy(buf[0:16], "cccccccccccccccc")
+	copy(buf[16:32], "bbbbbbbbbbbbbbbb")
+
+	guilty := s.Judge(0, buf)
+	if len(guilty) != 1 || guilty[0] != peerB {
+		t.Fatalf("expected only peerB blamed, got %v", guilty)
+	}
+}
+
+func TestSmartBanCache_JudgeSkipsOutOfRangeBlocks(t *testing.T) {
+	s := newSmartBanCache()
+
+	buf := make([]byte, 8)
+	// begin+length overruns buf, as could happen if Judge is ever called
+	// against a shorter piece than what was recorded.
+	s.Record(0, peerA, 0, []byte("0123456789"))
+
+	guilty := s.Judge(0, buf)
+	if len(guilty) != 0 {
+		t.Fatalf("expected no one blamed for an out-of-range block, got %v", guilty)
+	}
+}
+
+func TestSmartBanCache_ForgetDropsRecordedBlocks(t *testing.T) {
+	s := newSmartBanCache()
+
+	s.Record(0, peerA, 0, []byte("aaaaaaaaaaaaaaaa"))
+	s.Forget(0)
+
+	buf := make([]byte, 16)
+	copy(buf, "aaaaaaaaaaaaaaaa")
+
+	if guilty := s.Judge(0, buf); len(guilty) != 0 {
+		t.Fatalf("expected nothing recorded after Forget, got %v", guilty)
+	}
+}
+
+func TestSmartBanCache_JudgeIsolatesPieces(t *testing.T) {
+	s := newSmartBanCache()
+
+	s.Record(0, peerA, 0, []byte("aaaaaaaaaaaaaaaa"))
+	s.Record(1, peerB, 0, []byte("bbbbbbbbbbbbbbbb"))
+
+	buf := make([]byte, 16)
+	copy(buf, "aaaaaaaaaaaaaaaa")
+
+	guilty := s.Judge(1, buf)
+	if len(guilty) != 0 {
+		t.Fatalf("expected piece 1's judge to ignore piece 0's records, got %v", guilty)
+	}
+}
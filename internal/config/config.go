@@ -19,6 +19,17 @@ type Application struct {
 	// hard global connection limit
 	GlobalConnectionLimit uint16      `json:"global-connections-limit"`
 	Fallocate             atomic.Bool `json:"fallocate"`
+	// HashWorkers sets how many pieces can be hashed concurrently. 0 (the
+	// default) means runtime.NumCPU().
+	HashWorkers int `json:"hash-workers"`
+	// LogLevel is the default level ("trace".."panic", zerolog's naming)
+	// used for any subsystem not named in LogFilter.
+	LogLevel string `json:"log-level"`
+	// LogFormat is "json" (the default) or "console".
+	LogFormat string `json:"log-format"`
+	// LogFilter overrides LogLevel per subsystem, as a comma-separated
+	// "subsystem=level" list, e.g. "peer=warn,tracker=debug,client=info".
+	LogFilter string `json:"log-filter"`
 }
 
 type Config struct {
@@ -13,12 +13,11 @@ import (
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/kelindar/bitmap"
 	"github.com/puzpuzpuz/xsync/v3"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 
 	"github.com/negrel/assert"
 
 	"tyr/internal/pkg/empty"
+	"tyr/internal/pkg/log"
 	"tyr/internal/proto"
 	"tyr/internal/req"
 	"tyr/internal/util"
@@ -36,7 +35,7 @@ func newPeer(conn io.ReadWriteCloser, infoHash metainfo.Hash, pieceNum uint32, a
 	ctx, cancel := context.WithCancel(context.Background())
 	p := &Peer{
 		ctx:       ctx,
-		log:       log.With().Stringer("info_hash", infoHash).Str("addr", addr).Logger(),
+		log:       log.For("peer").With("info_hash", infoHash.HexString()).With("peer_addr", addr),
 		m:         sync.Mutex{},
 		Conn:      conn,
 		InfoHash:  infoHash,
@@ -53,22 +52,41 @@ func newPeer(conn io.ReadWriteCloser, infoHash metainfo.Hash, pieceNum uint32, a
 
 var ErrPeerSendInvalidData = errors.New("peer send invalid data")
 
+// BlockReporter is invoked with every block this Peer accepts from the
+// wire, before it's handed to resChan, so the owner can track provenance
+// (e.g. smart-ban) without Peer needing to know what a Download is.
+type BlockReporter func(res req.Response)
+
 type Peer struct {
-	log        zerolog.Logger
-	ctx        context.Context
-	Conn       io.ReadWriteCloser
-	resChan    chan<- req.Response
-	reqChan    chan req.Request
-	cancel     context.CancelFunc
-	requests   xsync.MapOf[req.Request, empty.Empty]
-	Address    string
-	Bitmap     bitmap.Bitmap
-	m          sync.Mutex
-	dead       atomic.Bool
-	bitmapLen  uint32
-	Choked     atomic.Bool
-	Interested atomic.Bool
-	InfoHash   torrent.InfoHash
+	log           log.Logger
+	ctx           context.Context
+	Conn          io.ReadWriteCloser
+	resChan       chan<- req.Response
+	reqChan       chan req.Request
+	cancel        context.CancelFunc
+	requests      xsync.MapOf[req.Request, empty.Empty]
+	blockReporter BlockReporter
+	Address       string
+	Bitmap        bitmap.Bitmap
+	m             sync.Mutex
+	dead          atomic.Bool
+	bitmapLen     uint32
+	Choked        atomic.Bool
+	Interested    atomic.Bool
+	InfoHash      torrent.InfoHash
+}
+
+// SetResChan wires the channel accepted blocks are delivered on. It must be
+// called by whoever registers this Peer with a Download before the peer's
+// read loop can forward anything.
+func (p *Peer) SetResChan(ch chan<- req.Response) {
+	p.resChan = ch
+}
+
+// SetBlockReporter wires the callback used to report each accepted block's
+// provenance, e.g. Download.recordBlock for the smart-ban cache.
+func (p *Peer) SetBlockReporter(r BlockReporter) {
+	p.blockReporter = r
 }
 
 type Event struct {
@@ -131,7 +149,9 @@ func (p *Peer) start(skipHandshake bool) {
 			p.log.Trace().Msgf("peer info hash mismatch %x", h.InfoHash)
 			return
 		}
-		p.log.Trace().Msgf("connect to peer %s", url.QueryEscape(string(h.PeerID[:])))
+
+		p.log = p.log.With("peer_id", url.QueryEscape(string(h.PeerID[:])))
+		p.log.Trace().Msg("connect to peer")
 	}
 
 	go func() {
@@ -186,6 +206,9 @@ func (p *Peer) start(skipHandshake bool) {
 				_ = p.Conn.Close()
 				return
 			}
+			if p.blockReporter != nil {
+				p.blockReporter(event.Res)
+			}
 			p.resChan <- event.Res
 		case proto.Request:
 			p.reqChan <- event.Req
@@ -0,0 +1,298 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"golang.org/x/sync/semaphore"
+
+	"tyr/internal/pkg/log"
+	"tyr/internal/req"
+)
+
+// webSeedMaxAttempts bounds the exponential backoff retry loop for a single
+// range request; after this many failed attempts the request is given up on
+// and the block stays unrequested so the regular scheduler can re-issue it,
+// possibly against a BitTorrent peer instead.
+const webSeedMaxAttempts = 5
+
+// NewWebSeed builds a BEP-19 HTTP peer for a single url-list entry. Unlike
+// Peer it never dials anything up front; every Request call makes its own
+// ranged HTTP request(s), so there's no handshake or long-lived connection
+// to manage.
+func NewWebSeed(rawURL string, info metainfo.Info, infoHash metainfo.Hash, hc *http.Client, concurrency int64) *WebSeedPeer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebSeedPeer{
+		ctx:      ctx,
+		cancel:   cancel,
+		log:      log.For("webseed").With("info_hash", infoHash.HexString()).With("url", rawURL),
+		url:      rawURL,
+		info:     info,
+		hc:       hc,
+		sem:      semaphore.NewWeighted(concurrency),
+		InfoHash: infoHash,
+	}
+}
+
+// WebSeedPeer fetches piece data over HTTP Range requests against a
+// url-list entry (BEP 19), standing in for a normal BitTorrent Peer as a
+// source of blocks for Download's request scheduler.
+type WebSeedPeer struct {
+	ctx           context.Context
+	cancel        context.CancelFunc
+	log           log.Logger
+	hc            *http.Client
+	sem           *semaphore.Weighted
+	resChan       chan<- req.Response
+	blockReporter BlockReporter
+	url           string
+	info          metainfo.Info
+	dead          atomic.Bool
+	InfoHash      metainfo.Hash
+}
+
+// SetResChan wires the channel that completed ranges are delivered on. It is
+// called once, by whatever assembles the Download's peer set, before any
+// Request is issued.
+func (w *WebSeedPeer) SetResChan(ch chan<- req.Response) {
+	w.resChan = ch
+}
+
+// SetBlockReporter wires the callback used to report each fetched range's
+// provenance, e.g. Download.recordBlock for the smart-ban cache, mirroring
+// Peer.SetBlockReporter.
+func (w *WebSeedPeer) SetBlockReporter(r BlockReporter) {
+	w.blockReporter = r
+}
+
+// Dead reports whether this webseed has been closed, or has given up after
+// a non-retryable HTTP response, mirroring
+// Peer.Dead so the two can be used interchangeably by code that only needs
+// to know whether a source is still usable.
+func (w *WebSeedPeer) Dead() bool {
+	return w.dead.Load()
+}
+
+// Close stops any in-flight or future requests from this webseed.
+func (w *WebSeedPeer) Close() error {
+	w.dead.Store(true)
+	w.cancel()
+	return nil
+}
+
+// Request fetches the byte range described by r and, once complete, writes
+// a single req.Response carrying the whole range onto resChan, the same way
+// a BitTorrent Peer's Piece message does. A request spanning a file
+// boundary in a multi-file torrent is satisfied with one ranged GET per
+// file and stitched back together before being handed off, so callers can't
+// tell the data came from more than one source.
+func (w *WebSeedPeer) Request(r req.Request) error {
+	spans := fileSpans(w.info, pieceOffset(w.info, r.PieceIndex)+int64(r.Begin), int64(r.Length))
+	if len(spans) == 0 {
+		return fmt.Errorf("webseed: request %+v maps to no file", r)
+	}
+
+	buf := make([]byte, r.Length)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(spans))
+	for i, span := range spans {
+		if err := w.sem.Acquire(w.ctx, 1); err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func(i int, span fileSpan) {
+			defer wg.Done()
+			defer w.sem.Release(1)
+
+			errs[i] = w.fetchRange(span, buf[span.bufOffset:span.bufOffset+span.length])
+		}(i, span)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	res := req.Response{
+		PieceIndex: r.PieceIndex,
+		Begin:      r.Begin,
+		Data:       buf,
+	}
+
+	if w.blockReporter != nil {
+		w.blockReporter(res)
+	}
+
+	select {
+	case w.resChan <- res:
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+
+	return nil
+}
+
+// fetchRange performs a single ranged GET for span, retrying with
+// exponential backoff on 5xx and 429 responses (and on transport errors),
+// and writes the body into out. Any other status marks the webseed dead and
+// is returned without a retry.
+func (w *WebSeedPeer) fetchRange(span fileSpan, out []byte) error {
+	u := w.fileURL(span.path)
+
+	var lastErr error
+	for attempt := 0; attempt < webSeedMaxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1))*200*time.Millisecond + time.Duration(rand.Intn(100))*time.Millisecond
+			select {
+			case <-w.ctx.Done():
+				return w.ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		err := w.doFetchRange(u, span, out)
+		if err == nil {
+			return nil
+		}
+		if w.Dead() {
+			return err
+		}
+
+		lastErr = err
+		w.log.Debug().Err(err).Int("attempt", attempt).Msg("webseed range request failed, retrying")
+	}
+
+	return fmt.Errorf("webseed: giving up on %s after %d attempts: %w", u, webSeedMaxAttempts, lastErr)
+}
+
+func (w *WebSeedPeer) doFetchRange(u string, span fileSpan, out []byte) error {
+	httpReq, err := http.NewRequestWithContext(w.ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", span.from, span.from+span.length-1))
+
+	resp, err := w.hc.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent:
+		if resp.StatusCode == http.StatusOK {
+			// Range was ignored and the body starts at the beginning of the file.
+			if _, err = io.CopyN(io.Discard, resp.Body, span.from); err != nil {
+				return err
+			}
+		}
+		_, err = io.ReadFull(resp.Body, out)
+		return err
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webseed: %s responded %d", u, resp.StatusCode)
+	default:
+		w.dead.Store(true)
+		return fmt.Errorf("webseed: %s responded %d, giving up on this webseed", u, resp.StatusCode)
+	}
+}
+
+// fileURL maps a file's path components, as found in metainfo.Info.Files,
+// to the URL this webseed serves it at, following BEP 19: a single-file
+// torrent is served directly at the url-list entry, a multi-file torrent is
+// served under "<url>/<name>/<path...>".
+func (w *WebSeedPeer) fileURL(path []string) string {
+	if len(path) == 0 {
+		return w.url
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(w.url, "/"))
+	b.WriteByte('/')
+	b.WriteString(url.PathEscape(w.info.Name))
+	for _, p := range path {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(p))
+	}
+
+	return b.String()
+}
+
+// fileSpan is one file's share of a (possibly multi-file-spanning) request.
+type fileSpan struct {
+	path      []string
+	from      int64 // offset within the file
+	length    int64
+	bufOffset int64 // offset within the caller's output buffer
+}
+
+// pieceOffset returns the absolute byte offset of the start of piece within
+// the torrent's concatenated file data.
+func pieceOffset(info metainfo.Info, piece uint32) int64 {
+	return int64(piece) * info.PieceLength
+}
+
+// fileSpans maps the torrent-wide byte range [offset, offset+length) onto
+// the individual files of info, in order, so a request that happens to
+// cross a file boundary in a multi-file torrent can be satisfied with one
+// ranged GET per file.
+func fileSpans(info metainfo.Info, offset, length int64) []fileSpan {
+	files := info.UpvertedFiles()
+
+	var spans []fileSpan
+	var fileStart int64
+	var consumed int64
+	want := length
+
+	for _, f := range files {
+		fileEnd := fileStart + f.Length
+		reqStart := offset + consumed
+
+		if reqStart >= fileEnd {
+			fileStart = fileEnd
+			continue
+		}
+		if reqStart+want <= fileStart {
+			break
+		}
+
+		spanFrom := reqStart - fileStart
+		spanLen := minInt64(want, fileEnd-reqStart)
+
+		spans = append(spans, fileSpan{
+			path:      f.Path,
+			from:      spanFrom,
+			length:    spanLen,
+			bufOffset: consumed,
+		})
+
+		consumed += spanLen
+		want -= spanLen
+		fileStart = fileEnd
+
+		if want <= 0 {
+			break
+		}
+	}
+
+	return spans
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
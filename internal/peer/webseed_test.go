@@ -0,0 +1,77 @@
+package peer
+
+import (
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestPieceOffset(t *testing.T) {
+	info := metainfo.Info{PieceLength: 1 << 14}
+
+	if got := pieceOffset(info, 0); got != 0 {
+		t.Fatalf("pieceOffset(0) = %d, want 0", got)
+	}
+	if got := pieceOffset(info, 3); got != 3*(1<<14) {
+		t.Fatalf("pieceOffset(3) = %d, want %d", got, 3*(1<<14))
+	}
+}
+
+func TestFileSpans_SingleFile(t *testing.T) {
+	info := metainfo.Info{Length: 100}
+
+	spans := fileSpans(info, 10, 20)
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].from != 10 || spans[0].length != 20 || spans[0].bufOffset != 0 {
+		t.Fatalf("unexpected span %+v", spans[0])
+	}
+}
+
+func TestFileSpans_CrossesFileBoundary(t *testing.T) {
+	info := metainfo.Info{
+		Files: []metainfo.FileInfo{
+			{Path: []string{"a"}, Length: 10},
+			{Path: []string{"b"}, Length: 10},
+		},
+	}
+
+	// request [5, 15) straddles both files: 5 bytes from "a", 5 from "b".
+	spans := fileSpans(info, 5, 10)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	if spans[0].path[0] != "a" || spans[0].from != 5 || spans[0].length != 5 || spans[0].bufOffset != 0 {
+		t.Fatalf("unexpected first span %+v", spans[0])
+	}
+	if spans[1].path[0] != "b" || spans[1].from != 0 || spans[1].length != 5 || spans[1].bufOffset != 5 {
+		t.Fatalf("unexpected second span %+v", spans[1])
+	}
+}
+
+func TestFileSpans_SkipsFilesEntirelyBeforeOffset(t *testing.T) {
+	info := metainfo.Info{
+		Files: []metainfo.FileInfo{
+			{Path: []string{"a"}, Length: 10},
+			{Path: []string{"b"}, Length: 10},
+		},
+	}
+
+	spans := fileSpans(info, 12, 5)
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].path[0] != "b" || spans[0].from != 2 || spans[0].length != 5 {
+		t.Fatalf("unexpected span %+v", spans[0])
+	}
+}
+
+func TestFileSpans_EmptyWhenOutOfRange(t *testing.T) {
+	info := metainfo.Info{Length: 10}
+
+	if spans := fileSpans(info, 20, 5); len(spans) != 0 {
+		t.Fatalf("expected no spans past the end of the torrent, got %v", spans)
+	}
+}